@@ -0,0 +1,265 @@
+/*
+ * Copyright 2023 The Kubernetes Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	GpuDeviceType         = "gpu"
+	AcceleratorDeviceType = "accelerator"
+
+	NodeAllocationStateStatusReady    = "Ready"
+	NodeAllocationStateStatusNotReady = "NotReady"
+)
+
+// NodeAllocationStateConfig is used to locate the NodeAllocationState CRD
+// for a given node.
+type NodeAllocationStateConfig struct {
+	Name      string
+	Namespace string
+}
+
+// NodeAllocationState holds the allocation state for all GPU devices on a
+// single node.
+type NodeAllocationState struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NodeAllocationStateSpec `json:"spec,omitempty"`
+	Status string                  `json:"status"`
+}
+
+// NodeAllocationStateSpec is the spec for the NodeAllocationState CRD.
+type NodeAllocationStateSpec struct {
+	AllocatableDevices []AllocatableDevices        `json:"allocatableDevices,omitempty"`
+	AllocatedClaims    map[string]AllocatedDevices `json:"allocatedClaims,omitempty"`
+
+	// GpuReservations indexes the reservations held against each physical
+	// GPU (keyed by UUID), so remaining shareable capacity can be computed
+	// without walking every AllocatedClaims entry.
+	GpuReservations map[string][]GpuReservation `json:"gpuReservations,omitempty"`
+
+	// Topology is the per-pair interconnect matrix between GPU UUIDs on
+	// this node, populated by the node agent that writes this CRD. A pair
+	// missing from the matrix is assumed to have no direct link.
+	Topology map[string]map[string]LinkType `json:"topology,omitempty"`
+}
+
+// LinkType describes the interconnect between two GPUs on the same node,
+// ordered from the current best-case quality on down.
+type LinkType string
+
+const (
+	LinkTypeNVSwitch     LinkType = "NVSWITCH"
+	LinkTypeNVLink       LinkType = "NVLINK"
+	LinkTypePCIeSameRoot LinkType = "PCIE_SAME_ROOT"
+	LinkTypeCrossNUMA    LinkType = "CROSS_NUMA"
+)
+
+// Rank orders LinkType from worst (0) to best, so two links can be compared
+// with a MinLinkType floor. An empty or unrecognized LinkType ranks below
+// every known link type.
+func (l LinkType) Rank() int {
+	switch l {
+	case LinkTypeCrossNUMA:
+		return 1
+	case LinkTypePCIeSameRoot:
+		return 2
+	case LinkTypeNVLink:
+		return 3
+	case LinkTypeNVSwitch:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// AllocatableDevices represents a single allocatable device on a node,
+// tagged by its underlying device type.
+type AllocatableDevices struct {
+	Gpu         *AllocatableGpu         `json:"gpu,omitempty"`
+	Accelerator *AllocatableAccelerator `json:"accelerator,omitempty"`
+}
+
+// Type returns the device type backing this AllocatableDevices entry.
+func (d AllocatableDevices) Type() string {
+	switch {
+	case d.Gpu != nil:
+		return GpuDeviceType
+	case d.Accelerator != nil:
+		return AcceleratorDeviceType
+	default:
+		return "unknown"
+	}
+}
+
+// AllocatableGpu describes a single physical GPU available for allocation.
+type AllocatableGpu struct {
+	UUID string `json:"uuid"`
+
+	// MemoryMB and ComputePercent describe the device's total capacity,
+	// used to decide how many shareable claims can be packed onto it.
+	MemoryMB       int64 `json:"memoryMB,omitempty"`
+	ComputePercent int   `json:"computePercent,omitempty"`
+
+	// The remaining fields are surfaced as attributes for DeviceSelector
+	// constraints and affinities.
+	ComputeCapability string `json:"computeCapability,omitempty"`
+	CUDADriverVersion string `json:"cudaDriverVersion,omitempty"`
+	DriverVersion     string `json:"driverVersion,omitempty"`
+	ProductName       string `json:"productName,omitempty"`
+	PCIBusID          string `json:"pciBusID,omitempty"`
+}
+
+// Attributes returns this device's values keyed by the attribute names
+// referenced by DeviceSelector constraints and affinities.
+func (g *AllocatableGpu) Attributes() map[string]string {
+	return map[string]string{
+		"memory":              strconv.FormatInt(g.MemoryMB, 10),
+		"compute_capability":  g.ComputeCapability,
+		"cuda_driver_version": g.CUDADriverVersion,
+		"product_name":        g.ProductName,
+		"driver_version":      g.DriverVersion,
+		"pci_bus_id":          g.PCIBusID,
+	}
+}
+
+// AllocatedDevices represents the devices allocated to satisfy a single
+// ResourceClaim, tagged by its underlying device type.
+type AllocatedDevices struct {
+	Gpu         *AllocatedGpus         `json:"gpu,omitempty"`
+	Accelerator *AllocatedAccelerators `json:"accelerator,omitempty"`
+}
+
+// Type returns the device type backing this AllocatedDevices entry.
+func (d AllocatedDevices) Type() string {
+	switch {
+	case d.Gpu != nil:
+		return GpuDeviceType
+	case d.Accelerator != nil:
+		return AcceleratorDeviceType
+	default:
+		return "unknown"
+	}
+}
+
+// AllocatedGpus is the set of physical GPUs allocated to a single claim.
+type AllocatedGpus struct {
+	Devices []AllocatedGpu `json:"devices,omitempty"`
+}
+
+// AllocatedGpu identifies a single physical GPU allocated to a claim, along
+// with the slice of its capacity reserved for that claim.
+type AllocatedGpu struct {
+	UUID string `json:"uuid"`
+
+	MemoryMB       int64 `json:"memoryMB,omitempty"`
+	ComputePercent int   `json:"computePercent,omitempty"`
+}
+
+// GpuReservation is one claim's slice of a shared physical GPU.
+type GpuReservation struct {
+	ClaimUID string `json:"claimUID"`
+
+	MemoryMB       int64 `json:"memoryMB,omitempty"`
+	ComputePercent int   `json:"computePercent,omitempty"`
+}
+
+// AllocatableAccelerator describes a single non-GPU accelerator available
+// for allocation. It exists to prove that AllocatableDevices can carry
+// device types beyond Gpu; a real accelerator handler would likely track
+// more device-specific capacity fields here.
+type AllocatableAccelerator struct {
+	UUID string `json:"uuid"`
+}
+
+// AllocatedAccelerators is the set of physical accelerators allocated to a
+// single claim.
+type AllocatedAccelerators struct {
+	Devices []AllocatedAccelerator `json:"devices,omitempty"`
+}
+
+// AllocatedAccelerator identifies a single physical accelerator allocated
+// to a claim.
+type AllocatedAccelerator struct {
+	UUID string `json:"uuid"`
+}
+
+// NewNodeAllocationState creates a new, empty NodeAllocationState for the
+// node described by config.
+func NewNodeAllocationState(config *NodeAllocationStateConfig) *NodeAllocationState {
+	return &NodeAllocationState{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      config.Name,
+			Namespace: config.Namespace,
+		},
+		Spec: NodeAllocationStateSpec{
+			AllocatedClaims: make(map[string]AllocatedDevices),
+			GpuReservations: make(map[string][]GpuReservation),
+		},
+	}
+}
+
+// DeepCopy creates a deep copy of the receiver, creating a new
+// NodeAllocationState.
+func (in *NodeAllocationState) DeepCopy() *NodeAllocationState {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeAllocationState)
+	*out = *in
+	out.ObjectMeta = *in.ObjectMeta.DeepCopy()
+	in.Spec.DeepCopyInto(&out.Spec)
+	return out
+}
+
+// DeepCopyInto copies the receiver into out.
+func (in *NodeAllocationStateSpec) DeepCopyInto(out *NodeAllocationStateSpec) {
+	*out = *in
+	if in.AllocatableDevices != nil {
+		out.AllocatableDevices = make([]AllocatableDevices, len(in.AllocatableDevices))
+		copy(out.AllocatableDevices, in.AllocatableDevices)
+	}
+	if in.AllocatedClaims != nil {
+		out.AllocatedClaims = make(map[string]AllocatedDevices, len(in.AllocatedClaims))
+		for k, v := range in.AllocatedClaims {
+			out.AllocatedClaims[k] = v
+		}
+	}
+	if in.GpuReservations != nil {
+		out.GpuReservations = make(map[string][]GpuReservation, len(in.GpuReservations))
+		for k, v := range in.GpuReservations {
+			reservations := make([]GpuReservation, len(v))
+			copy(reservations, v)
+			out.GpuReservations[k] = reservations
+		}
+	}
+	if in.Topology != nil {
+		out.Topology = make(map[string]map[string]LinkType, len(in.Topology))
+		for uuid, links := range in.Topology {
+			peers := make(map[string]LinkType, len(links))
+			for peerUUID, linkType := range links {
+				peers[peerUUID] = linkType
+			}
+			out.Topology[uuid] = peers
+		}
+	}
+}
@@ -24,7 +24,8 @@ const (
 	GroupName = "gpu.resource.example.com"
 	Version   = "v1alpha1"
 
-	GpuClaimParametersKind = "GpuClaimParameters"
+	GpuClaimParametersKind         = "GpuClaimParameters"
+	AcceleratorClaimParametersKind = "AcceleratorClaimParameters"
 )
 
 func DefaultDeviceClassParametersSpec() *DeviceClassParametersSpec {
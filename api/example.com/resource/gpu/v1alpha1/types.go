@@ -0,0 +1,162 @@
+/*
+ * Copyright 2023 The Kubernetes Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	nascrd "github.com/xcuda-io/k8s-xcuda-dra/api/example.com/resource/gpu/nas/v1alpha1"
+)
+
+// DeviceClassParameters is the CRD holding the spec for a ResourceClass'
+// ParametersRef.
+type DeviceClassParameters struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec DeviceClassParametersSpec `json:"spec,omitempty"`
+}
+
+// DeviceClassParametersSpec is the spec for the DeviceClassParameters CRD.
+type DeviceClassParametersSpec struct {
+	DeviceSelector []DeviceSelector `json:"deviceSelector,omitempty"`
+}
+
+// DeviceSelector selects devices by type and name, optionally narrowed by
+// attribute constraints and scored by attribute affinities.
+type DeviceSelector struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+
+	// Constraints must all be satisfied by a device's attributes for it to
+	// be considered a candidate.
+	Constraints []DeviceConstraint `json:"constraints,omitempty"`
+
+	// Affinities score candidate devices that remain after Constraints are
+	// applied; a device's score is the sum of the weights of the
+	// affinities it satisfies, and for single-device claims the highest
+	// scoring device is chosen. Claims with Count > 1 do not use
+	// affinities: their devices are chosen by topology, then UUID order.
+	Affinities []DeviceAffinity `json:"affinities,omitempty"`
+}
+
+// ConstraintOperator is the comparison applied between a device attribute
+// and the constraint or affinity's value.
+type ConstraintOperator string
+
+const (
+	OpEqual              ConstraintOperator = "="
+	OpNotEqual           ConstraintOperator = "!="
+	OpLessThan           ConstraintOperator = "<"
+	OpLessThanOrEqual    ConstraintOperator = "<="
+	OpGreaterThan        ConstraintOperator = ">"
+	OpGreaterThanOrEqual ConstraintOperator = ">="
+	OpRegex              ConstraintOperator = "regex"
+	OpSetContains        ConstraintOperator = "set_contains"
+	OpSetContainsAny     ConstraintOperator = "set_contains_any"
+)
+
+// DeviceConstraint compares a named device attribute against Value using
+// Operator. Numeric operators fall back to lexicographic string comparison
+// when either side fails to parse as a number.
+type DeviceConstraint struct {
+	Attribute string             `json:"attribute"`
+	Operator  ConstraintOperator `json:"operator"`
+	Value     string             `json:"value"`
+}
+
+// DeviceAffinity is a DeviceConstraint that contributes Weight to a
+// device's score instead of excluding it when unsatisfied.
+type DeviceAffinity struct {
+	Attribute string             `json:"attribute"`
+	Operator  ConstraintOperator `json:"operator"`
+	Value     string             `json:"value"`
+	Weight    int64              `json:"weight"`
+}
+
+// GpuClaimParameters is the CRD holding the spec for a ResourceClaim's
+// ParametersRef.
+type GpuClaimParameters struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec GpuClaimParametersSpec `json:"spec,omitempty"`
+}
+
+// GpuClaimParametersSpec is the spec for the GpuClaimParameters CRD.
+type GpuClaimParametersSpec struct {
+	Count int `json:"count"`
+
+	// MemoryMB and ComputePercent request a fractional slice of a single
+	// physical GPU instead of exclusive ownership of it. Both are ignored
+	// unless Shareable is true.
+	MemoryMB       int64 `json:"memoryMB,omitempty"`
+	ComputePercent int   `json:"computePercent,omitempty"`
+
+	// Shareable indicates that this claim is willing to share a physical
+	// GPU with other shareable claims, as long as their combined MemoryMB
+	// and ComputePercent fit within the device's capacity.
+	Shareable bool `json:"shareable,omitempty"`
+
+	// Selectors are additional claim-level constraints and affinities,
+	// combined with the DeviceClass' DeviceSelector when choosing a device.
+	Selectors []DeviceSelector `json:"selectors,omitempty"`
+
+	// TopologyPolicy controls how much Count > 1 allocations care about the
+	// interconnect between the devices they're given. MinLinkType sets the
+	// floor a pair of devices must meet to count as "connected"; it is
+	// ignored when TopologyPolicy is None. An unset TopologyPolicy is
+	// treated like BestEffort.
+	TopologyPolicy TopologyPolicy  `json:"topologyPolicy,omitempty"`
+	MinLinkType    nascrd.LinkType `json:"minLinkType,omitempty"`
+
+	// MinCUDADriverVersion and MinDriverVersion reject devices reporting an
+	// older CUDA driver / GPU driver version than requested, e.g. "12.4".
+	MinCUDADriverVersion string `json:"minCUDADriverVersion,omitempty"`
+	MinDriverVersion     string `json:"minDriverVersion,omitempty"`
+}
+
+// AcceleratorClaimParameters is the CRD holding the spec for a
+// ResourceClaim's ParametersRef when requesting a generic (non-GPU)
+// accelerator.
+type AcceleratorClaimParameters struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec AcceleratorClaimParametersSpec `json:"spec,omitempty"`
+}
+
+// AcceleratorClaimParametersSpec is the spec for the
+// AcceleratorClaimParameters CRD.
+type AcceleratorClaimParametersSpec struct {
+	Count int `json:"count"`
+}
+
+// TopologyPolicy controls how strictly a multi-GPU claim's devices must be
+// interconnected.
+type TopologyPolicy string
+
+const (
+	// TopologyPolicyNone ignores topology entirely when choosing devices.
+	TopologyPolicyNone TopologyPolicy = "None"
+	// TopologyPolicyBestEffort prefers better-connected devices but still
+	// allocates when no well-connected set is available.
+	TopologyPolicyBestEffort TopologyPolicy = "BestEffort"
+	// TopologyPolicyRequired rejects nodes where no subset of devices
+	// meets MinLinkType on every pair.
+	TopologyPolicyRequired TopologyPolicy = "Required"
+)
@@ -0,0 +1,168 @@
+/*
+ * Copyright 2023 The Kubernetes Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	resourcev1 "k8s.io/api/resource/v1alpha2"
+	"k8s.io/dynamic-resource-allocation/controller"
+
+	nascrd "github.com/xcuda-io/k8s-xcuda-dra/api/example.com/resource/gpu/nas/v1alpha1"
+	gpucrd "github.com/xcuda-io/k8s-xcuda-dra/api/example.com/resource/gpu/v1alpha1"
+)
+
+// acceleratordriver implements DeviceTypeHandler for generic, non-GPU
+// accelerators. It exists to prove that a second device type can be
+// registered alongside gpudriver; unlike gpudriver it has no notion of
+// sharing, topology or device attributes, so each claim is satisfied by
+// exclusively assigning it Count whole, interchangeable devices.
+type acceleratordriver struct {
+	PendingAllocatedClaims *PerNodeAllocatedClaims
+}
+
+var _ DeviceTypeHandler = &acceleratordriver{}
+
+func NewAcceleratorDriver() *acceleratordriver {
+	return &acceleratordriver{
+		PendingAllocatedClaims: NewPerNodeAllocatedClaims(),
+	}
+}
+
+// Kind returns the ResourceClaim.ParametersRef.Kind this handler validates
+// and allocates for.
+func (a *acceleratordriver) Kind() string {
+	return gpucrd.AcceleratorClaimParametersKind
+}
+
+func (a *acceleratordriver) ValidateClaimParameters(claimParameters interface{}) error {
+	claimParams, ok := claimParameters.(*gpucrd.AcceleratorClaimParametersSpec)
+	if !ok {
+		return fmt.Errorf("unsupported claim parameters type: %T", claimParameters)
+	}
+	if claimParams.Count < 1 {
+		return fmt.Errorf("invalid number of accelerators requested: %v", claimParams.Count)
+	}
+	return nil
+}
+
+func (a *acceleratordriver) Allocate(crd *nascrd.NodeAllocationState, claim *resourcev1.ResourceClaim, claimParameters interface{}, class *resourcev1.ResourceClass, classParameters interface{}, selectedNode string) (OnSuccessCallback, error) {
+	if _, ok := claimParameters.(*gpucrd.AcceleratorClaimParametersSpec); !ok {
+		return nil, fmt.Errorf("unsupported claim parameters type: %T", claimParameters)
+	}
+
+	claimUID := string(claim.UID)
+
+	if !a.PendingAllocatedClaims.Exists(claimUID, selectedNode) {
+		return nil, fmt.Errorf("no allocations generated for claim '%v' on node '%v' yet", claim.UID, selectedNode)
+	}
+
+	devices := a.PendingAllocatedClaims.Get(claimUID, selectedNode)
+	crd.Spec.AllocatedClaims[claimUID] = devices
+
+	onSuccess := func() {
+		a.PendingAllocatedClaims.Remove(claimUID)
+	}
+
+	return onSuccess, nil
+}
+
+func (a *acceleratordriver) Deallocate(crd *nascrd.NodeAllocationState, claim *resourcev1.ResourceClaim) error {
+	a.PendingAllocatedClaims.Remove(string(claim.UID))
+	return nil
+}
+
+func (a *acceleratordriver) UnsuitableNode(crd *nascrd.NodeAllocationState, pod *corev1.Pod, cas []*controller.ClaimAllocation, allcas []*controller.ClaimAllocation, potentialNode string) error {
+	a.PendingAllocatedClaims.VisitNode(potentialNode, func(claimUID string, allocation nascrd.AllocatedDevices) {
+		if _, exists := crd.Spec.AllocatedClaims[claimUID]; exists {
+			a.PendingAllocatedClaims.Remove(claimUID)
+		} else {
+			crd.Spec.AllocatedClaims[claimUID] = allocation
+		}
+	})
+
+	available := a.availableDevices(crd)
+
+	for _, ca := range cas {
+		claimUID := string(ca.Claim.UID)
+		if existing, exists := crd.Spec.AllocatedClaims[claimUID]; exists {
+			for _, device := range existing.Accelerator.Devices {
+				delete(available, device.UUID)
+			}
+			continue
+		}
+
+		claimParams, _ := ca.ClaimParameters.(*gpucrd.AcceleratorClaimParametersSpec)
+
+		picked := pickAccelerators(available, claimParams.Count)
+		if len(picked) != claimParams.Count {
+			for _, ca := range allcas {
+				ca.UnsuitableNodes = append(ca.UnsuitableNodes, potentialNode)
+			}
+			return nil
+		}
+
+		var devices []nascrd.AllocatedAccelerator
+		for _, uuid := range picked {
+			delete(available, uuid)
+			devices = append(devices, nascrd.AllocatedAccelerator{UUID: uuid})
+		}
+
+		allocatedDevices := nascrd.AllocatedDevices{
+			Accelerator: &nascrd.AllocatedAccelerators{Devices: devices},
+		}
+		a.PendingAllocatedClaims.Set(claimUID, potentialNode, allocatedDevices)
+	}
+
+	return nil
+}
+
+// availableDevices returns the accelerator UUIDs on crd's node that are not
+// already reserved by an AllocatedClaims entry.
+func (a *acceleratordriver) availableDevices(crd *nascrd.NodeAllocationState) map[string]bool {
+	available := make(map[string]bool)
+	for _, device := range crd.Spec.AllocatableDevices {
+		if device.Accelerator != nil {
+			available[device.Accelerator.UUID] = true
+		}
+	}
+	for _, devices := range crd.Spec.AllocatedClaims {
+		if devices.Accelerator == nil {
+			continue
+		}
+		for _, device := range devices.Accelerator.Devices {
+			delete(available, device.UUID)
+		}
+	}
+	return available
+}
+
+// pickAccelerators picks count UUIDs from available, sorted for
+// determinism. It returns fewer than count if available is too small.
+func pickAccelerators(available map[string]bool, count int) []string {
+	uuids := make([]string, 0, len(available))
+	for uuid := range available {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+	if len(uuids) > count {
+		uuids = uuids[:count]
+	}
+	return uuids
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright 2023 The Kubernetes Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	resourcev1 "k8s.io/api/resource/v1alpha2"
+	"k8s.io/dynamic-resource-allocation/controller"
+
+	nascrd "github.com/xcuda-io/k8s-xcuda-dra/api/example.com/resource/gpu/nas/v1alpha1"
+	gpucrd "github.com/xcuda-io/k8s-xcuda-dra/api/example.com/resource/gpu/v1alpha1"
+)
+
+// DeviceTypeHandler implements the allocation lifecycle for one kind of
+// device. Registering a handler with driver makes it participate in
+// Allocate, Deallocate and UnsuitableNodes alongside every other registered
+// type; its claim parameters type must also be added to
+// driver.GetClaimParameters and claimParametersKind.
+type DeviceTypeHandler interface {
+	// Kind is the ResourceClaim.Spec.ParametersRef.Kind this handler
+	// validates and allocates for.
+	Kind() string
+
+	ValidateClaimParameters(claimParameters interface{}) error
+
+	Allocate(crd *nascrd.NodeAllocationState, claim *resourcev1.ResourceClaim, claimParameters interface{}, class *resourcev1.ResourceClass, classParameters interface{}, selectedNode string) (OnSuccessCallback, error)
+
+	Deallocate(crd *nascrd.NodeAllocationState, claim *resourcev1.ResourceClaim) error
+
+	UnsuitableNode(crd *nascrd.NodeAllocationState, pod *corev1.Pod, cas []*controller.ClaimAllocation, allcas []*controller.ClaimAllocation, potentialNode string) error
+}
+
+// deviceTypeRegistry looks up the DeviceTypeHandler responsible for a
+// ResourceClaim.ParametersRef.Kind or an AllocatedDevices.Type(), so driver
+// can dispatch Allocate, Deallocate and UnsuitableNode without knowing about
+// any specific device type. A handler is reachable from both maps once
+// registered.
+type deviceTypeRegistry struct {
+	byKind       map[string]DeviceTypeHandler
+	byDeviceType map[string]DeviceTypeHandler
+}
+
+func newDeviceTypeRegistry() *deviceTypeRegistry {
+	return &deviceTypeRegistry{
+		byKind:       make(map[string]DeviceTypeHandler),
+		byDeviceType: make(map[string]DeviceTypeHandler),
+	}
+}
+
+// register makes handler reachable by both its own Kind() and deviceType,
+// the AllocatedDevices.Type() value it produces.
+func (r *deviceTypeRegistry) register(deviceType string, handler DeviceTypeHandler) {
+	r.byKind[handler.Kind()] = handler
+	r.byDeviceType[deviceType] = handler
+}
+
+func (r *deviceTypeRegistry) forKind(kind string) (DeviceTypeHandler, bool) {
+	handler, ok := r.byKind[kind]
+	return handler, ok
+}
+
+func (r *deviceTypeRegistry) forDeviceType(deviceType string) (DeviceTypeHandler, bool) {
+	handler, ok := r.byDeviceType[deviceType]
+	return handler, ok
+}
+
+// kinds returns every registered ParametersRef.Kind, for callers that need
+// to partition a set of ClaimAllocations by kind.
+func (r *deviceTypeRegistry) kinds() []string {
+	kinds := make([]string, 0, len(r.byKind))
+	for kind := range r.byKind {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+// claimParametersKind returns the ResourceClaim.ParametersRef.Kind that
+// corresponds to the concrete type of claimParameters, so callers can look
+// up a handler without needing claim.Spec.ParametersRef itself (which is
+// nil for claims relying on the default GpuClaimParametersSpec).
+func claimParametersKind(claimParameters interface{}) (string, error) {
+	switch claimParameters.(type) {
+	case *gpucrd.GpuClaimParametersSpec:
+		return gpucrd.GpuClaimParametersKind, nil
+	case *gpucrd.AcceleratorClaimParametersSpec:
+		return gpucrd.AcceleratorClaimParametersKind, nil
+	default:
+		return "", fmt.Errorf("unknown ResourceClaimParameters type: %T", claimParameters)
+	}
+}
@@ -22,7 +22,9 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	resourcev1 "k8s.io/api/resource/v1alpha2"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/dynamic-resource-allocation/controller"
 
 	nascrd "github.com/xcuda-io/k8s-xcuda-dra/api/example.com/resource/gpu/nas/v1alpha1"
@@ -33,26 +35,53 @@ import (
 
 const (
 	DriverAPIGroup = gpucrd.GroupName
+
+	// ClaimFinalizer is added to every ResourceClaim the driver has
+	// reserved devices for, before the NodeAllocationState CRD update that
+	// records the reservation, and removed only after the CRD update that
+	// clears it in Deallocate. This keeps a claim (and the reservation it
+	// implies) from being garbage collected out from under a controller
+	// crash between those two updates; claimReconciler sweeps any
+	// reservation that still ends up stranded.
+	ClaimFinalizer = DriverAPIGroup + "/allocated"
 )
 
 type OnSuccessCallback func()
 
 type driver struct {
-	lock      *PerNodeMutex
-	namespace string
-	clientset clientset.Interface
-	gpu       *gpudriver
+	lock       *PerNodeMutex
+	namespace  string
+	clientset  clientset.Interface
+	coreclient kubernetes.Interface
+	gpu        *gpudriver
+	handlers   *deviceTypeRegistry
 }
 
 var _ controller.Driver = &driver{}
 
 func NewDriver(config *Config) *driver {
-	return &driver{
-		lock:      NewPerNodeMutex(),
-		namespace: config.namespace,
-		clientset: config.clientSets.Example,
-		gpu:       NewGpuDriver(),
+	gpu := NewGpuDriver()
+	accelerator := NewAcceleratorDriver()
+
+	handlers := newDeviceTypeRegistry()
+	handlers.register(nascrd.GpuDeviceType, gpu)
+	handlers.register(nascrd.AcceleratorDeviceType, accelerator)
+
+	d := &driver{
+		lock:       NewPerNodeMutex(),
+		namespace:  config.namespace,
+		clientset:  config.clientSets.Example,
+		coreclient: config.clientSets.Core,
+		gpu:        gpu,
+		handlers:   handlers,
 	}
+
+	// Background sweep for reservations stranded by a crash between the
+	// NodeAllocationState CRD update and the finalizer add/remove that
+	// should have bracketed it.
+	go newClaimReconciler(d.lock, d.clientset, d.coreclient, d.namespace, d.handlers).Run(context.Background())
+
+	return d
 }
 
 func (d driver) GetClassParameters(ctx context.Context, class *resourcev1.ResourceClass) (interface{}, error) {
@@ -77,17 +106,32 @@ func (d driver) GetClaimParameters(ctx context.Context, claim *resourcev1.Resour
 		return nil, fmt.Errorf("incorrect API group: %v", claim.Spec.ParametersRef.APIGroup)
 	}
 
+	handler, ok := d.handlers.forKind(claim.Spec.ParametersRef.Kind)
+	if !ok {
+		return nil, fmt.Errorf("unknown ResourceClaim.ParametersRef.Kind: %v", claim.Spec.ParametersRef.Kind)
+	}
+
 	switch claim.Spec.ParametersRef.Kind {
 	case gpucrd.GpuClaimParametersKind:
 		gc, err := d.clientset.GpuV1alpha1().GpuClaimParameters(claim.Namespace).Get(ctx, claim.Spec.ParametersRef.Name, metav1.GetOptions{})
 		if err != nil {
 			return nil, fmt.Errorf("error getting GpuClaimParameters called '%v' in namespace '%v': %v", claim.Spec.ParametersRef.Name, claim.Namespace, err)
 		}
-		err = d.gpu.ValidateClaimParameters(&gc.Spec)
+		err = handler.ValidateClaimParameters(&gc.Spec)
 		if err != nil {
 			return nil, fmt.Errorf("error validating GpuClaimParameters called '%v' in namespace '%v': %v", claim.Spec.ParametersRef.Name, claim.Namespace, err)
 		}
 		return &gc.Spec, nil
+	case gpucrd.AcceleratorClaimParametersKind:
+		ac, err := d.clientset.GpuV1alpha1().AcceleratorClaimParameters(claim.Namespace).Get(ctx, claim.Spec.ParametersRef.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error getting AcceleratorClaimParameters called '%v' in namespace '%v': %v", claim.Spec.ParametersRef.Name, claim.Namespace, err)
+		}
+		err = handler.ValidateClaimParameters(&ac.Spec)
+		if err != nil {
+			return nil, fmt.Errorf("error validating AcceleratorClaimParameters called '%v' in namespace '%v': %v", claim.Spec.ParametersRef.Name, claim.Namespace, err)
+		}
+		return &ac.Spec, nil
 	default:
 		return nil, fmt.Errorf("unknown ResourceClaim.ParametersRef.Kind: %v", claim.Spec.ParametersRef.Kind)
 	}
@@ -99,14 +143,14 @@ func (d driver) Allocate(ctx context.Context, cas []*controller.ClaimAllocation,
 	// for every d.allocate() looped call.
 	// E.g.: selectedNode=="" check, client stup and CRD fetching.
 	for _, ca := range cas {
-		ca.Allocation, ca.Error = d.allocate(ctx, ca.Claim, ca.ClaimParameters, ca.Class, ca.ClassParameters, selectedNode)
+		ca.Allocation, ca.Error = d.allocate(ctx, ca.Claim, ca.ClaimParameters, ca.Class, ca.ClassParameters, selectedNode, ca.UnsuitableNodes)
 	}
 }
 
-func (d driver) allocate(ctx context.Context, claim *resourcev1.ResourceClaim, claimParameters interface{}, class *resourcev1.ResourceClass, classParameters interface{}, selectedNode string) (*resourcev1.AllocationResult, error) {
+func (d driver) allocate(ctx context.Context, claim *resourcev1.ResourceClaim, claimParameters interface{}, class *resourcev1.ResourceClass, classParameters interface{}, selectedNode string, excludeNodes []string) (*resourcev1.AllocationResult, error) {
 
 	if selectedNode == "" {
-		return nil, fmt.Errorf("TODO: immediate allocations is not yet supported")
+		return d.allocateImmediate(ctx, claim, claimParameters, class, classParameters, excludeNodes)
 	}
 
 	d.lock.Get(selectedNode).Lock()
@@ -132,31 +176,164 @@ func (d driver) allocate(ctx context.Context, claim *resourcev1.ResourceClaim, c
 		crd.Spec.AllocatedClaims = make(map[string]nascrd.AllocatedDevices)
 	}
 
-	if _, exists := crd.Spec.AllocatedClaims[string(claim.UID)]; exists {
-		return buildAllocationResult(selectedNode, true), nil
+	shareable := false
+	if gpuClaimParams, ok := claimParameters.(*gpucrd.GpuClaimParametersSpec); ok {
+		shareable = gpuClaimParams.Shareable
 	}
 
-	var onSuccess OnSuccessCallback
-	classParams, _ := classParameters.(*gpucrd.DeviceClassParametersSpec)
+	if _, exists := crd.Spec.AllocatedClaims[string(claim.UID)]; exists {
+		return buildAllocationResult(selectedNode, shareable), nil
+	}
 
-	switch claimParams := claimParameters.(type) {
-	case *gpucrd.GpuClaimParametersSpec:
-		onSuccess, err = d.gpu.Allocate(crd, claim, claimParams, class, classParams, selectedNode)
-	default:
-		err = fmt.Errorf("unknown ResourceClaim.ParametersRef.Kind: %v", claim.Spec.ParametersRef.Kind)
+	kind, err := claimParametersKind(claimParameters)
+	if err != nil {
+		return nil, err
+	}
+	handler, ok := d.handlers.forKind(kind)
+	if !ok {
+		return nil, fmt.Errorf("unknown ResourceClaim.ParametersRef.Kind: %v", kind)
 	}
+
+	onSuccess, err := handler.Allocate(crd, claim, claimParameters, class, classParameters, selectedNode)
 	if err != nil {
 		return nil, fmt.Errorf("unable to allocate devices on node '%v': %v", selectedNode, err)
 	}
 
+	if err := addClaimFinalizer(ctx, d.coreclient, claim); err != nil {
+		return nil, fmt.Errorf("error adding finalizer to claim '%v': %v", claim.UID, err)
+	}
+
 	err = client.Update(ctx, &crd.Spec)
 	if err != nil {
+		// The finalizer was added but the reservation it was meant to
+		// protect was never persisted, so compensate by removing it again
+		// rather than leaving the claim unable to terminate.
+		if removeErr := removeClaimFinalizer(ctx, d.coreclient, claim); removeErr != nil {
+			return nil, fmt.Errorf("error updating NodeAllocationState CRD: %v (and failed to remove the finalizer added for this attempt: %v)", err, removeErr)
+		}
 		return nil, fmt.Errorf("error updating NodeAllocationState CRD: %v", err)
 	}
 
 	onSuccess()
 
-	return buildAllocationResult(selectedNode, true), nil
+	return buildAllocationResult(selectedNode, shareable), nil
+}
+
+// allocateImmediate implements immediate allocation mode: it scores every
+// candidate node's feasibility using the same constraint/affinity/topology
+// path as UnsuitableNode, picks the best one, and persists the reservation
+// on it directly (there is no prior PendingAllocatedClaims entry to consume,
+// since no scheduling pass ran). It only supports Gpu claims: scoring a node
+// across device types needs a way to compare scores from unrelated
+// handlers, which DeviceTypeHandler does not define, so other kinds are
+// rejected with an error.
+func (d driver) allocateImmediate(ctx context.Context, claim *resourcev1.ResourceClaim, claimParameters interface{}, class *resourcev1.ResourceClass, classParameters interface{}, excludeNodes []string) (*resourcev1.AllocationResult, error) {
+	claimParams, ok := claimParameters.(*gpucrd.GpuClaimParametersSpec)
+	if !ok {
+		return nil, fmt.Errorf("immediate allocation is not supported for ResourceClaim.ParametersRef.Kind: %v", claim.Spec.ParametersRef.Kind)
+	}
+	classParams, _ := classParameters.(*gpucrd.DeviceClassParametersSpec)
+
+	excluded := make(map[string]bool, len(excludeNodes))
+	for _, node := range excludeNodes {
+		excluded[node] = true
+	}
+
+	list, err := d.clientset.NasV1alpha1().NodeAllocationStates(d.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error listing NodeAllocationState CRDs: %v", err)
+	}
+
+	var bestNode string
+	var bestScore int64
+	found := false
+	for i := range list.Items {
+		crd := &list.Items[i]
+		if excluded[crd.Name] {
+			continue
+		}
+
+		d.lock.Get(crd.Name).Lock()
+		score, _, feasible := d.gpu.scoreNode(crd, claim, claimParams, class, classParams)
+		d.lock.Get(crd.Name).Unlock()
+
+		if feasible && (!found || score > bestScore) {
+			found = true
+			bestNode = crd.Name
+			bestScore = score
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("no suitable node found for immediate allocation of claim '%v'", claim.UID)
+	}
+
+	const maxReserveAttempts = 5
+	for attempt := 0; attempt < maxReserveAttempts; attempt++ {
+		result, err := d.reserveOnNode(ctx, bestNode, claim, claimParams, class, classParams)
+		if err == nil {
+			return result, nil
+		}
+		if !errors.IsConflict(err) {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("exceeded retries reserving claim '%v' on node '%v' after concurrent NodeAllocationState updates", claim.UID, bestNode)
+}
+
+// reserveOnNode re-reads selectedNode's NodeAllocationState under its lock,
+// re-validates feasibility against that fresh copy, and persists the
+// reservation. A stale read surfaces as a resourceVersion conflict from
+// client.Update, which the caller retries.
+func (d driver) reserveOnNode(ctx context.Context, selectedNode string, claim *resourcev1.ResourceClaim, claimParams *gpucrd.GpuClaimParametersSpec, class *resourcev1.ResourceClass, classParams *gpucrd.DeviceClassParametersSpec) (*resourcev1.AllocationResult, error) {
+	d.lock.Get(selectedNode).Lock()
+	defer d.lock.Get(selectedNode).Unlock()
+
+	crdconfig := &nascrd.NodeAllocationStateConfig{
+		Name:      selectedNode,
+		Namespace: d.namespace,
+	}
+	crd := nascrd.NewNodeAllocationState(crdconfig)
+
+	client := nasclient.New(crd, d.clientset.NasV1alpha1())
+	err := client.Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving node specific Gpu CRD: %v", err)
+	}
+
+	if crd.Status != nascrd.NodeAllocationStateStatusReady {
+		return nil, fmt.Errorf("NodeAllocationStateStatus: %v", crd.Status)
+	}
+	if crd.Spec.AllocatedClaims == nil {
+		crd.Spec.AllocatedClaims = make(map[string]nascrd.AllocatedDevices)
+	}
+
+	if _, exists := crd.Spec.AllocatedClaims[string(claim.UID)]; exists {
+		return buildAllocationResult(selectedNode, claimParams.Shareable), nil
+	}
+
+	_, devices, feasible := d.gpu.scoreNode(crd, claim, claimParams, class, classParams)
+	if !feasible {
+		return nil, fmt.Errorf("node '%v' is no longer suitable for claim '%v'", selectedNode, claim.UID)
+	}
+
+	d.gpu.reserve(crd, claim, devices)
+
+	if err := addClaimFinalizer(ctx, d.coreclient, claim); err != nil {
+		return nil, fmt.Errorf("error adding finalizer to claim '%v': %v", claim.UID, err)
+	}
+
+	if err := client.Update(ctx, &crd.Spec); err != nil {
+		// The finalizer was added but the reservation it was meant to
+		// protect was never persisted, so compensate by removing it again
+		// rather than leaving the claim unable to terminate.
+		if removeErr := removeClaimFinalizer(ctx, d.coreclient, claim); removeErr != nil {
+			return nil, fmt.Errorf("%v (and failed to remove the finalizer added for this attempt: %v)", err, removeErr)
+		}
+		return nil, err
+	}
+
+	return buildAllocationResult(selectedNode, claimParams.Shareable), nil
 }
 
 func (d driver) Deallocate(ctx context.Context, claim *resourcev1.ResourceClaim) error {
@@ -189,12 +366,11 @@ func (d driver) Deallocate(ctx context.Context, claim *resourcev1.ResourceClaim)
 	}
 
 	devices := crd.Spec.AllocatedClaims[string(claim.UID)]
-	switch devices.Type() {
-	case nascrd.GpuDeviceType:
-		err = d.gpu.Deallocate(crd, claim)
-	default:
-		err = fmt.Errorf("unknown AllocatedDevices.Type(): %v", devices.Type())
+	handler, ok := d.handlers.forDeviceType(devices.Type())
+	if !ok {
+		return fmt.Errorf("unknown AllocatedDevices.Type(): %v", devices.Type())
 	}
+	err = handler.Deallocate(crd, claim)
 	if err != nil {
 		return fmt.Errorf("unable to deallocate devices '%v': %v", devices, err)
 	}
@@ -206,6 +382,10 @@ func (d driver) Deallocate(ctx context.Context, claim *resourcev1.ResourceClaim)
 		return fmt.Errorf("error updating NodeAllocationState CRD: %v", err)
 	}
 
+	if err := removeClaimFinalizer(ctx, d.coreclient, claim); err != nil {
+		return fmt.Errorf("error removing finalizer from claim '%v': %v", claim.UID, err)
+	}
+
 	return nil
 }
 
@@ -256,22 +436,20 @@ func (d driver) unsuitableNode(ctx context.Context, pod *corev1.Pod, allcas []*c
 
 	perKindCas := make(map[string][]*controller.ClaimAllocation)
 	for _, ca := range allcas {
-		switch ca.ClaimParameters.(type) {
-		case *gpucrd.GpuClaimParametersSpec:
-			perKindCas[gpucrd.GpuClaimParametersKind] = append(perKindCas[gpucrd.GpuClaimParametersKind], ca)
-		default:
-			return fmt.Errorf("unknown ResourceClaimParameters kind: %T", ca.ClaimParameters)
+		kind, err := claimParametersKind(ca.ClaimParameters)
+		if err != nil {
+			return err
 		}
+		perKindCas[kind] = append(perKindCas[kind], ca)
 	}
-	for _, kind := range []string{gpucrd.GpuClaimParametersKind} {
-		var err error
-		switch kind {
-		case gpucrd.GpuClaimParametersKind:
-			err = d.gpu.UnsuitableNode(crd, pod, perKindCas[kind], allcas, potentialNode)
-		default:
-			err = fmt.Errorf("unknown ResourceClaimParameters kind: %+v", kind)
+	// Every registered kind runs, so a node carrying a mix of device types
+	// (e.g. GPUs and generic accelerators) is evaluated against all of them.
+	for _, kind := range d.handlers.kinds() {
+		if len(perKindCas[kind]) == 0 {
+			continue
 		}
-		if err != nil {
+		handler, _ := d.handlers.forKind(kind)
+		if err := handler.UnsuitableNode(crd, pod, perKindCas[kind], allcas, potentialNode); err != nil {
 			return fmt.Errorf("error processing '%v': %v", kind, err)
 		}
 	}
@@ -300,6 +478,52 @@ func buildAllocationResult(selectedNode string, shareable bool) *resourcev1.Allo
 	return allocation
 }
 
+// addClaimFinalizer adds ClaimFinalizer to claim if it is not already
+// present.
+func addClaimFinalizer(ctx context.Context, coreclient kubernetes.Interface, claim *resourcev1.ResourceClaim) error {
+	if hasFinalizer(claim, ClaimFinalizer) {
+		return nil
+	}
+	updated := claim.DeepCopy()
+	updated.Finalizers = append(updated.Finalizers, ClaimFinalizer)
+	result, err := coreclient.ResourceV1alpha2().ResourceClaims(claim.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	*claim = *result
+	return nil
+}
+
+// removeClaimFinalizer removes ClaimFinalizer from claim if present.
+func removeClaimFinalizer(ctx context.Context, coreclient kubernetes.Interface, claim *resourcev1.ResourceClaim) error {
+	if !hasFinalizer(claim, ClaimFinalizer) {
+		return nil
+	}
+	updated := claim.DeepCopy()
+	updated.Finalizers = withoutString(updated.Finalizers, ClaimFinalizer)
+	_, err := coreclient.ResourceV1alpha2().ResourceClaims(claim.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+func hasFinalizer(claim *resourcev1.ResourceClaim, finalizer string) bool {
+	for _, f := range claim.Finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+func withoutString(s []string, target string) []string {
+	var kept []string
+	for _, v := range s {
+		if v != target {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
 func getSelectedNode(claim *resourcev1.ResourceClaim) string {
 	if claim.Status.Allocation == nil {
 		return ""
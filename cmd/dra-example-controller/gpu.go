@@ -18,6 +18,7 @@ package main
 
 import (
 	"fmt"
+	"sort"
 
 	corev1 "k8s.io/api/core/v1"
 	resourcev1 "k8s.io/api/resource/v1alpha2"
@@ -31,27 +32,73 @@ type gpudriver struct {
 	PendingAllocatedClaims *PerNodeAllocatedClaims
 }
 
+var _ DeviceTypeHandler = &gpudriver{}
+
 func NewGpuDriver() *gpudriver {
 	return &gpudriver{
 		PendingAllocatedClaims: NewPerNodeAllocatedClaims(),
 	}
 }
 
-func (g *gpudriver) ValidateClaimParameters(claimParams *gpucrd.GpuClaimParametersSpec) error {
+// Kind returns the ResourceClaim.ParametersRef.Kind this handler validates
+// and allocates for.
+func (g *gpudriver) Kind() string {
+	return gpucrd.GpuClaimParametersKind
+}
+
+func (g *gpudriver) ValidateClaimParameters(claimParameters interface{}) error {
+	claimParams, ok := claimParameters.(*gpucrd.GpuClaimParametersSpec)
+	if !ok {
+		return fmt.Errorf("unsupported claim parameters type: %T", claimParameters)
+	}
 	if claimParams.Count < 1 {
 		return fmt.Errorf("invalid number of GPUs requested: %v", claimParams.Count)
 	}
+	if claimParams.MemoryMB < 0 {
+		return fmt.Errorf("invalid memoryMB requested: %v", claimParams.MemoryMB)
+	}
+	if claimParams.ComputePercent < 0 || claimParams.ComputePercent > 100 {
+		return fmt.Errorf("invalid computePercent requested: %v", claimParams.ComputePercent)
+	}
+	if claimParams.Shareable && claimParams.MemoryMB == 0 && claimParams.ComputePercent == 0 {
+		return fmt.Errorf("shareable claims must request a nonzero memoryMB or computePercent")
+	}
+	if err := validateVersion(claimParams.MinCUDADriverVersion); err != nil {
+		return fmt.Errorf("invalid minCUDADriverVersion: %v", err)
+	}
+	if err := validateVersion(claimParams.MinDriverVersion); err != nil {
+		return fmt.Errorf("invalid minDriverVersion: %v", err)
+	}
 	return nil
 }
 
-func (g *gpudriver) Allocate(crd *nascrd.NodeAllocationState, claim *resourcev1.ResourceClaim, claimParams *gpucrd.GpuClaimParametersSpec, class *resourcev1.ResourceClass, classParams *gpucrd.DeviceClassParametersSpec, selectedNode string) (OnSuccessCallback, error) {
+func (g *gpudriver) Allocate(crd *nascrd.NodeAllocationState, claim *resourcev1.ResourceClaim, claimParameters interface{}, class *resourcev1.ResourceClass, classParameters interface{}, selectedNode string) (OnSuccessCallback, error) {
+	if _, ok := claimParameters.(*gpucrd.GpuClaimParametersSpec); !ok {
+		return nil, fmt.Errorf("unsupported claim parameters type: %T", claimParameters)
+	}
+
 	claimUID := string(claim.UID)
 
 	if !g.PendingAllocatedClaims.Exists(claimUID, selectedNode) {
 		return nil, fmt.Errorf("no allocations generated for claim '%v' on node '%v' yet", claim.UID, selectedNode)
 	}
 
-	crd.Spec.AllocatedClaims[claimUID] = g.PendingAllocatedClaims.Get(claimUID, selectedNode)
+	devices := g.PendingAllocatedClaims.Get(claimUID, selectedNode)
+	crd.Spec.AllocatedClaims[claimUID] = devices
+
+	if crd.Spec.GpuReservations == nil {
+		crd.Spec.GpuReservations = make(map[string][]nascrd.GpuReservation)
+	}
+	if devices.Gpu != nil {
+		for _, device := range devices.Gpu.Devices {
+			crd.Spec.GpuReservations[device.UUID] = append(crd.Spec.GpuReservations[device.UUID], nascrd.GpuReservation{
+				ClaimUID:       claimUID,
+				MemoryMB:       device.MemoryMB,
+				ComputePercent: device.ComputePercent,
+			})
+		}
+	}
+
 	onSuccess := func() {
 		g.PendingAllocatedClaims.Remove(claimUID)
 	}
@@ -60,7 +107,18 @@ func (g *gpudriver) Allocate(crd *nascrd.NodeAllocationState, claim *resourcev1.
 }
 
 func (g *gpudriver) Deallocate(crd *nascrd.NodeAllocationState, claim *resourcev1.ResourceClaim) error {
-	g.PendingAllocatedClaims.Remove(string(claim.UID))
+	claimUID := string(claim.UID)
+	g.PendingAllocatedClaims.Remove(claimUID)
+
+	if devices, exists := crd.Spec.AllocatedClaims[claimUID]; exists && devices.Gpu != nil {
+		if crd.Spec.GpuReservations == nil {
+			crd.Spec.GpuReservations = make(map[string][]nascrd.GpuReservation)
+		}
+		for _, device := range devices.Gpu.Devices {
+			crd.Spec.GpuReservations[device.UUID] = removeGpuReservation(crd.Spec.GpuReservations[device.UUID], claimUID)
+		}
+	}
+
 	return nil
 }
 
@@ -85,17 +143,9 @@ func (g *gpudriver) UnsuitableNode(crd *nascrd.NodeAllocationState, pod *corev1.
 			return nil
 		}
 
-		var devices []nascrd.AllocatedGpu
-		for _, gpu := range allocated[claimUID] {
-			device := nascrd.AllocatedGpu{
-				UUID: gpu,
-			}
-			devices = append(devices, device)
-		}
-
 		allocatedDevices := nascrd.AllocatedDevices{
 			Gpu: &nascrd.AllocatedGpus{
-				Devices: devices,
+				Devices: allocated[claimUID],
 			},
 		}
 
@@ -105,51 +155,235 @@ func (g *gpudriver) UnsuitableNode(crd *nascrd.NodeAllocationState, pod *corev1.
 	return nil
 }
 
-func (g *gpudriver) allocate(crd *nascrd.NodeAllocationState, pod *corev1.Pod, gpucas []*controller.ClaimAllocation, allcas []*controller.ClaimAllocation, node string) map[string][]string {
-	available := make(map[string]*nascrd.AllocatableGpu)
+// gpuCapacity tracks how much of a physical GPU's memory and compute are
+// still unreserved.
+type gpuCapacity struct {
+	MemoryMB       int64
+	ComputePercent int
+}
 
+// remainingCapacity computes the unreserved capacity of every allocatable
+// GPU on the node, based on the reservations already recorded in the CRD.
+func remainingCapacity(crd *nascrd.NodeAllocationState) map[string]*gpuCapacity {
+	remaining := make(map[string]*gpuCapacity)
 	for _, device := range crd.Spec.AllocatableDevices {
-		switch device.Type() {
-		case nascrd.GpuDeviceType:
-			available[device.Gpu.UUID] = device.Gpu
-		default:
-			// skip other devices
+		if device.Gpu == nil {
+			continue
+		}
+		remaining[device.Gpu.UUID] = &gpuCapacity{
+			MemoryMB:       device.Gpu.MemoryMB,
+			ComputePercent: device.Gpu.ComputePercent,
 		}
 	}
+	for uuid, reservations := range crd.Spec.GpuReservations {
+		capacity, exists := remaining[uuid]
+		if !exists {
+			continue
+		}
+		for _, r := range reservations {
+			capacity.MemoryMB -= r.MemoryMB
+			capacity.ComputePercent -= r.ComputePercent
+		}
+	}
+	return remaining
+}
 
-	for _, allocation := range crd.Spec.AllocatedClaims {
-		switch allocation.Type() {
-		case nascrd.GpuDeviceType:
-			for _, device := range allocation.Gpu.Devices {
-				delete(available, device.UUID)
+// eligibleCandidates returns, sorted by UUID for determinism, every device
+// that can satisfy claimParams on its own: a fully unused device for
+// exclusive (non-shareable) claims, or any device with enough spare memory
+// and compute for shareable ones, filtered by every constraint in selectors.
+func eligibleCandidates(available map[string]*nascrd.AllocatableGpu, remaining map[string]*gpuCapacity, claimParams *gpucrd.GpuClaimParametersSpec, selectors []gpucrd.DeviceSelector) []string {
+	constraints := gpuConstraints(selectors)
+
+	var candidates []string
+	for uuid, device := range available {
+		capacity := remaining[uuid]
+		if !claimParams.Shareable {
+			if capacity.MemoryMB != device.MemoryMB || capacity.ComputePercent != device.ComputePercent {
+				continue
 			}
-		default:
-			// skip other devices
+		} else if capacity.MemoryMB < claimParams.MemoryMB || capacity.ComputePercent < claimParams.ComputePercent {
+			continue
+		}
+		if !matchesConstraints(device.Attributes(), constraints) {
+			continue
+		}
+		if !versionAtLeast(device.CUDADriverVersion, claimParams.MinCUDADriverVersion) {
+			continue
 		}
+		if !versionAtLeast(device.DriverVersion, claimParams.MinDriverVersion) {
+			continue
+		}
+		candidates = append(candidates, uuid)
 	}
+	sort.Strings(candidates)
+	return candidates
+}
+
+// pickDevice picks the single best candidate: the one with the highest
+// summed affinity weight, ties broken by UUID so the result is
+// deterministic.
+func pickDevice(candidates []string, available map[string]*nascrd.AllocatableGpu, selectors []gpucrd.DeviceSelector) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	affinities := gpuAffinities(selectors)
+
+	sort.Slice(candidates, func(i, j int) bool {
+		si := affinityScore(available[candidates[i]].Attributes(), affinities)
+		sj := affinityScore(available[candidates[j]].Attributes(), affinities)
+		if si != sj {
+			return si > sj
+		}
+		return candidates[i] < candidates[j]
+	})
+
+	return candidates[0]
+}
 
-	allocated := make(map[string][]string)
+func (g *gpudriver) allocate(crd *nascrd.NodeAllocationState, pod *corev1.Pod, gpucas []*controller.ClaimAllocation, allcas []*controller.ClaimAllocation, node string) map[string][]nascrd.AllocatedGpu {
+	available := make(map[string]*nascrd.AllocatableGpu)
+	for _, device := range crd.Spec.AllocatableDevices {
+		if device.Gpu != nil {
+			available[device.Gpu.UUID] = device.Gpu
+		}
+	}
+
+	remaining := remainingCapacity(crd)
+
+	allocated := make(map[string][]nascrd.AllocatedGpu)
 	for _, ca := range gpucas {
 		claimUID := string(ca.Claim.UID)
-		if _, exists := crd.Spec.AllocatedClaims[claimUID]; exists {
-			devices := crd.Spec.AllocatedClaims[claimUID].Gpu.Devices
-			for _, device := range devices {
-				allocated[claimUID] = append(allocated[claimUID], device.UUID)
-			}
+		if existing, exists := crd.Spec.AllocatedClaims[claimUID]; exists {
+			allocated[claimUID] = existing.Gpu.Devices
 			continue
 		}
 
 		claimParams, _ := ca.ClaimParameters.(*gpucrd.GpuClaimParametersSpec)
-		var devices []string
-		for i := 0; i < claimParams.Count; i++ {
-			for _, device := range available {
-				devices = append(devices, device.UUID)
-				delete(available, device.UUID)
-				break
+
+		var selectors []gpucrd.DeviceSelector
+		if classParams, ok := ca.ClassParameters.(*gpucrd.DeviceClassParametersSpec); ok && classParams != nil {
+			selectors = append(selectors, classParams.DeviceSelector...)
+		}
+		selectors = append(selectors, claimParams.Selectors...)
+
+		var picked []string
+		if claimParams.Count <= 1 {
+			candidates := eligibleCandidates(available, remaining, claimParams, selectors)
+			if uuid := pickDevice(candidates, available, selectors); uuid != "" {
+				picked = []string{uuid}
+			}
+		} else {
+			picked = pickDeviceSet(available, remaining, claimParams, selectors, crd.Spec.Topology)
+		}
+
+		var devices []nascrd.AllocatedGpu
+		for _, uuid := range picked {
+			device := available[uuid]
+			memoryMB, computePercent := device.MemoryMB, device.ComputePercent
+			if claimParams.Shareable {
+				memoryMB, computePercent = claimParams.MemoryMB, claimParams.ComputePercent
 			}
+
+			remaining[uuid].MemoryMB -= memoryMB
+			remaining[uuid].ComputePercent -= computePercent
+
+			devices = append(devices, nascrd.AllocatedGpu{
+				UUID:           uuid,
+				MemoryMB:       memoryMB,
+				ComputePercent: computePercent,
+			})
 		}
 		allocated[claimUID] = devices
 	}
 
 	return allocated
 }
+
+// scoreNode runs the same device-selection logic as UnsuitableNode against a
+// single claim, without mutating crd or g.PendingAllocatedClaims. It reports
+// whether the claim fits on this node and, if so, which devices it would
+// get and a score reflecting how good a fit they are (summed affinity
+// weight for single-device claims, interconnect quality for multi-GPU
+// ones) so callers can compare candidate nodes.
+func (g *gpudriver) scoreNode(crd *nascrd.NodeAllocationState, claim *resourcev1.ResourceClaim, claimParams *gpucrd.GpuClaimParametersSpec, class *resourcev1.ResourceClass, classParams *gpucrd.DeviceClassParametersSpec) (int64, []nascrd.AllocatedGpu, bool) {
+	if crd.Status != nascrd.NodeAllocationStateStatusReady {
+		return 0, nil, false
+	}
+
+	ca := &controller.ClaimAllocation{
+		Claim:           claim,
+		ClaimParameters: claimParams,
+		Class:           class,
+		ClassParameters: classParams,
+	}
+
+	devices := g.allocate(crd, nil, []*controller.ClaimAllocation{ca}, nil, crd.Name)[string(claim.UID)]
+	if len(devices) != claimParams.Count {
+		return 0, nil, false
+	}
+
+	var selectors []gpucrd.DeviceSelector
+	if classParams != nil {
+		selectors = append(selectors, classParams.DeviceSelector...)
+	}
+	selectors = append(selectors, claimParams.Selectors...)
+
+	var score int64
+	if claimParams.Count <= 1 {
+		if attrs := deviceAttributes(crd, devices[0].UUID); attrs != nil {
+			score = affinityScore(attrs, gpuAffinities(selectors))
+		}
+	} else {
+		uuids := make([]string, len(devices))
+		for i, device := range devices {
+			uuids[i] = device.UUID
+		}
+		score = int64(subsetScore(uuids, crd.Spec.Topology, effectiveMinRank(claimParams.MinLinkType)))
+	}
+
+	return score, devices, true
+}
+
+// deviceAttributes looks up the attributes of the allocatable GPU with the
+// given UUID, or nil if it is not present on this node.
+func deviceAttributes(crd *nascrd.NodeAllocationState, uuid string) map[string]string {
+	for _, device := range crd.Spec.AllocatableDevices {
+		if device.Gpu != nil && device.Gpu.UUID == uuid {
+			return device.Gpu.Attributes()
+		}
+	}
+	return nil
+}
+
+// reserve records devices as allocated to claim directly on crd, bypassing
+// PendingAllocatedClaims. It is used by immediate allocation, which has no
+// prior UnsuitableNode pass to stage the reservation.
+func (g *gpudriver) reserve(crd *nascrd.NodeAllocationState, claim *resourcev1.ResourceClaim, devices []nascrd.AllocatedGpu) {
+	claimUID := string(claim.UID)
+
+	crd.Spec.AllocatedClaims[claimUID] = nascrd.AllocatedDevices{
+		Gpu: &nascrd.AllocatedGpus{Devices: devices},
+	}
+
+	if crd.Spec.GpuReservations == nil {
+		crd.Spec.GpuReservations = make(map[string][]nascrd.GpuReservation)
+	}
+	for _, device := range devices {
+		crd.Spec.GpuReservations[device.UUID] = append(crd.Spec.GpuReservations[device.UUID], nascrd.GpuReservation{
+			ClaimUID:       claimUID,
+			MemoryMB:       device.MemoryMB,
+			ComputePercent: device.ComputePercent,
+		})
+	}
+}
+
+func removeGpuReservation(reservations []nascrd.GpuReservation, claimUID string) []nascrd.GpuReservation {
+	var kept []nascrd.GpuReservation
+	for _, r := range reservations {
+		if r.ClaimUID != claimUID {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
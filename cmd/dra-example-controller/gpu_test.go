@@ -0,0 +1,69 @@
+/*
+ * Copyright 2023 The Kubernetes Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+
+	resourcev1 "k8s.io/api/resource/v1alpha2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	nascrd "github.com/xcuda-io/k8s-xcuda-dra/api/example.com/resource/gpu/nas/v1alpha1"
+)
+
+// TestGpuDriverDeallocateStrandedSharedReservation exercises the cleanup
+// path claimReconciler.reconcileNode now delegates to: a shareable GPU
+// claim's AllocatedClaims entry is gone from the claim's perspective (it was
+// never fetched, only its UID is known), but its GpuReservation must still
+// be purged so remainingCapacity recovers the slice it was holding.
+func TestGpuDriverDeallocateStrandedSharedReservation(t *testing.T) {
+	const strandedClaimUID = "stranded-claim"
+	const otherClaimUID = "other-claim"
+
+	crd := &nascrd.NodeAllocationState{
+		Spec: nascrd.NodeAllocationStateSpec{
+			AllocatedClaims: map[string]nascrd.AllocatedDevices{
+				strandedClaimUID: {
+					Gpu: &nascrd.AllocatedGpus{
+						Devices: []nascrd.AllocatedGpu{{UUID: "gpu0", MemoryMB: 4000, ComputePercent: 25}},
+					},
+				},
+			},
+			GpuReservations: map[string][]nascrd.GpuReservation{
+				"gpu0": {
+					{ClaimUID: strandedClaimUID, MemoryMB: 4000, ComputePercent: 25},
+					{ClaimUID: otherClaimUID, MemoryMB: 4000, ComputePercent: 25},
+				},
+			},
+		},
+	}
+
+	g := NewGpuDriver()
+	// reconcileNode only knows the stranded claim's UID, not a live
+	// ResourceClaim, so it builds a minimal stand-in carrying just that.
+	strandedClaim := &resourcev1.ResourceClaim{ObjectMeta: metav1.ObjectMeta{UID: types.UID(strandedClaimUID)}}
+
+	if err := g.Deallocate(crd, strandedClaim); err != nil {
+		t.Fatalf("Deallocate() returned error: %v", err)
+	}
+
+	reservations := crd.Spec.GpuReservations["gpu0"]
+	if len(reservations) != 1 || reservations[0].ClaimUID != otherClaimUID {
+		t.Errorf("GpuReservations[gpu0] = %+v, want only the other claim's reservation to remain", reservations)
+	}
+}
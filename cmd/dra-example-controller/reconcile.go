@@ -0,0 +1,196 @@
+/*
+ * Copyright 2023 The Kubernetes Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	resourcev1 "k8s.io/api/resource/v1alpha2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	nascrd "github.com/xcuda-io/k8s-xcuda-dra/api/example.com/resource/gpu/nas/v1alpha1"
+	nasclient "github.com/xcuda-io/k8s-xcuda-dra/api/example.com/resource/gpu/nas/v1alpha1/client"
+	clientset "github.com/xcuda-io/k8s-xcuda-dra/pkg/example.com/resource/clientset/versioned"
+)
+
+// claimReconcilerResync bounds how long a stranded reservation can survive
+// undetected when no ResourceClaim delete event arrives for it, e.g. because
+// the informer's watch was re-established after the delete was missed.
+const claimReconcilerResync = 5 * time.Minute
+
+// claimReconciler garbage-collects AllocatedClaims entries left on a
+// NodeAllocationState by a controller crash between the CRD update and the
+// ClaimFinalizer add/remove that should have bracketed it: an entry is
+// stranded once its ResourceClaim no longer exists or is terminal (being
+// deleted), since ClaimFinalizer is all that was holding it back.
+type claimReconciler struct {
+	lock       *PerNodeMutex
+	clientset  clientset.Interface
+	coreclient kubernetes.Interface
+	namespace  string
+	handlers   *deviceTypeRegistry
+}
+
+func newClaimReconciler(lock *PerNodeMutex, clientset clientset.Interface, coreclient kubernetes.Interface, namespace string, handlers *deviceTypeRegistry) *claimReconciler {
+	return &claimReconciler{
+		lock:       lock,
+		clientset:  clientset,
+		coreclient: coreclient,
+		namespace:  namespace,
+		handlers:   handlers,
+	}
+}
+
+// Run starts a namespace-wide ResourceClaim informer and sweeps every
+// NodeAllocationState whenever it reports a claim deleted, once up front
+// after the informer syncs, and on a fixed backstop interval. It blocks
+// until ctx is done, so callers should run it in its own goroutine.
+func (r *claimReconciler) Run(ctx context.Context) {
+	factory := informers.NewSharedInformerFactoryWithOptions(r.coreclient, claimReconcilerResync, informers.WithNamespace(r.namespace))
+	informer := factory.Resource().V1alpha2().ResourceClaims().Informer()
+
+	informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj interface{}) {
+			r.reconcileAll(ctx)
+		},
+	})
+
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		klog.Error("stranded claim reconciler: failed to sync ResourceClaim informer")
+		return
+	}
+
+	r.reconcileAll(ctx)
+
+	ticker := time.NewTicker(claimReconcilerResync)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reconcileAll(ctx)
+		}
+	}
+}
+
+// reconcileAll walks every NodeAllocationState in the namespace and
+// garbage-collects its stranded AllocatedClaims entries.
+func (r *claimReconciler) reconcileAll(ctx context.Context) {
+	list, err := r.clientset.NasV1alpha1().NodeAllocationStates(r.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		klog.Errorf("stranded claim reconciler: error listing NodeAllocationState CRDs: %v", err)
+		return
+	}
+
+	for i := range list.Items {
+		if err := r.reconcileNode(ctx, &list.Items[i]); err != nil {
+			klog.Errorf("stranded claim reconciler: error reconciling node '%v': %v", list.Items[i].Name, err)
+		}
+	}
+}
+
+// reconcileNode removes every AllocatedClaims entry on crd that is stranded,
+// routing each through its owning DeviceTypeHandler's Deallocate first so
+// device-type-specific state (e.g. a GpuReservation) doesn't outlive the
+// claim it was reserved for, then releasing the finalizer on its
+// ResourceClaim if that claim is still present (just terminal). It takes the
+// same per-node lock as allocate, Deallocate and unsuitableNode, so its
+// Get/Update never races theirs.
+func (r *claimReconciler) reconcileNode(ctx context.Context, crd *nascrd.NodeAllocationState) error {
+	if len(crd.Spec.AllocatedClaims) == 0 {
+		return nil
+	}
+
+	r.lock.Get(crd.Name).Lock()
+	defer r.lock.Get(crd.Name).Unlock()
+
+	client := nasclient.New(crd, r.clientset.NasV1alpha1())
+	if err := client.Get(ctx); err != nil {
+		return fmt.Errorf("error retrieving NodeAllocationState CRD: %v", err)
+	}
+
+	changed := false
+	for claimUID, devices := range crd.Spec.AllocatedClaims {
+		claim, err := r.findClaim(ctx, claimUID)
+		if err != nil {
+			return fmt.Errorf("error looking up claim '%v': %v", claimUID, err)
+		}
+		if claim != nil && claim.DeletionTimestamp == nil {
+			continue
+		}
+
+		handler, ok := r.handlers.forDeviceType(devices.Type())
+		if !ok {
+			return fmt.Errorf("unknown device type for stranded claim '%v': %v", claimUID, devices.Type())
+		}
+
+		// The claim may already be gone from the API entirely, not just
+		// terminal, so Deallocate is given a minimal stand-in carrying only
+		// the UID its implementations actually key off of.
+		deallocClaim := claim
+		if deallocClaim == nil {
+			deallocClaim = &resourcev1.ResourceClaim{ObjectMeta: metav1.ObjectMeta{UID: types.UID(claimUID)}}
+		}
+		if err := handler.Deallocate(crd, deallocClaim); err != nil {
+			return fmt.Errorf("error deallocating stranded claim '%v': %v", claimUID, err)
+		}
+
+		if claim != nil {
+			if err := removeClaimFinalizer(ctx, r.coreclient, claim); err != nil {
+				return fmt.Errorf("error removing finalizer from stranded claim '%v': %v", claimUID, err)
+			}
+		}
+
+		delete(crd.Spec.AllocatedClaims, claimUID)
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return client.Update(ctx, &crd.Spec)
+}
+
+// findClaim returns the ResourceClaim backing claimUID, or nil if none
+// exists. UIDs aren't a server-side list filter, so this walks every claim
+// in the namespace; AllocatedClaims entries are expected to be far fewer
+// than the cluster's total claim count, so this runs rarely relative to
+// that walk.
+func (r *claimReconciler) findClaim(ctx context.Context, claimUID string) (*resourcev1.ResourceClaim, error) {
+	claims, err := r.coreclient.ResourceV1alpha2().ResourceClaims(r.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range claims.Items {
+		if string(claims.Items[i].UID) == claimUID {
+			return &claims.Items[i], nil
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright 2023 The Kubernetes Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	nascrd "github.com/xcuda-io/k8s-xcuda-dra/api/example.com/resource/gpu/nas/v1alpha1"
+	gpucrd "github.com/xcuda-io/k8s-xcuda-dra/api/example.com/resource/gpu/v1alpha1"
+)
+
+// gpuConstraints collects the constraints from every GPU DeviceSelector in
+// selectors (class-level and claim-level selectors are passed in together).
+func gpuConstraints(selectors []gpucrd.DeviceSelector) []gpucrd.DeviceConstraint {
+	var constraints []gpucrd.DeviceConstraint
+	for _, selector := range selectors {
+		if selector.Type != nascrd.GpuDeviceType {
+			continue
+		}
+		constraints = append(constraints, selector.Constraints...)
+	}
+	return constraints
+}
+
+// gpuAffinities collects the affinities from every GPU DeviceSelector in
+// selectors.
+func gpuAffinities(selectors []gpucrd.DeviceSelector) []gpucrd.DeviceAffinity {
+	var affinities []gpucrd.DeviceAffinity
+	for _, selector := range selectors {
+		if selector.Type != nascrd.GpuDeviceType {
+			continue
+		}
+		affinities = append(affinities, selector.Affinities...)
+	}
+	return affinities
+}
+
+// matchesConstraints reports whether attrs satisfies every constraint.
+func matchesConstraints(attrs map[string]string, constraints []gpucrd.DeviceConstraint) bool {
+	for _, c := range constraints {
+		if !evaluateOperator(attrs[c.Attribute], c.Value, c.Operator) {
+			return false
+		}
+	}
+	return true
+}
+
+// affinityScore sums the weight of every affinity attrs satisfies.
+func affinityScore(attrs map[string]string, affinities []gpucrd.DeviceAffinity) int64 {
+	var score int64
+	for _, a := range affinities {
+		if evaluateOperator(attrs[a.Attribute], a.Value, a.Operator) {
+			score += a.Weight
+		}
+	}
+	return score
+}
+
+func evaluateOperator(actual, value string, op gpucrd.ConstraintOperator) bool {
+	switch op {
+	case gpucrd.OpEqual:
+		return actual == value
+	case gpucrd.OpNotEqual:
+		return actual != value
+	case gpucrd.OpLessThan, gpucrd.OpLessThanOrEqual, gpucrd.OpGreaterThan, gpucrd.OpGreaterThanOrEqual:
+		return compareOrdered(actual, value, op)
+	case gpucrd.OpRegex:
+		matched, err := regexp.MatchString(value, actual)
+		return err == nil && matched
+	case gpucrd.OpSetContains:
+		return setContainsAll(splitSet(actual), value)
+	case gpucrd.OpSetContainsAny:
+		return setContainsAny(splitSet(actual), splitSet(value))
+	default:
+		return false
+	}
+}
+
+// compareOrdered compares actual and value numerically when both parse as
+// floats, falling back to a lexicographic string comparison otherwise.
+func compareOrdered(actual, value string, op gpucrd.ConstraintOperator) bool {
+	af, aerr := strconv.ParseFloat(actual, 64)
+	vf, verr := strconv.ParseFloat(value, 64)
+	if aerr == nil && verr == nil {
+		switch op {
+		case gpucrd.OpLessThan:
+			return af < vf
+		case gpucrd.OpLessThanOrEqual:
+			return af <= vf
+		case gpucrd.OpGreaterThan:
+			return af > vf
+		case gpucrd.OpGreaterThanOrEqual:
+			return af >= vf
+		}
+	}
+	switch op {
+	case gpucrd.OpLessThan:
+		return actual < value
+	case gpucrd.OpLessThanOrEqual:
+		return actual <= value
+	case gpucrd.OpGreaterThan:
+		return actual > value
+	case gpucrd.OpGreaterThanOrEqual:
+		return actual >= value
+	}
+	return false
+}
+
+func splitSet(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func setContainsAll(set []string, value string) bool {
+	for _, s := range set {
+		if s == value {
+			return true
+		}
+	}
+	return false
+}
+
+func setContainsAny(set []string, values []string) bool {
+	for _, v := range values {
+		if setContainsAll(set, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2023 The Kubernetes Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+
+	gpucrd "github.com/xcuda-io/k8s-xcuda-dra/api/example.com/resource/gpu/v1alpha1"
+)
+
+func TestMatchesConstraints(t *testing.T) {
+	attrs := map[string]string{
+		"model":  "A100",
+		"memory": "80",
+		"tags":   "ml,fast",
+	}
+
+	tests := []struct {
+		name        string
+		constraints []gpucrd.DeviceConstraint
+		want        bool
+	}{
+		{"equal match", []gpucrd.DeviceConstraint{{Attribute: "model", Operator: gpucrd.OpEqual, Value: "A100"}}, true},
+		{"equal mismatch", []gpucrd.DeviceConstraint{{Attribute: "model", Operator: gpucrd.OpEqual, Value: "H100"}}, false},
+		{"not equal", []gpucrd.DeviceConstraint{{Attribute: "model", Operator: gpucrd.OpNotEqual, Value: "H100"}}, true},
+		{"numeric greater-equal", []gpucrd.DeviceConstraint{{Attribute: "memory", Operator: gpucrd.OpGreaterThanOrEqual, Value: "80"}}, true},
+		{"numeric less-than fails", []gpucrd.DeviceConstraint{{Attribute: "memory", Operator: gpucrd.OpLessThan, Value: "80"}}, false},
+		{"regex match", []gpucrd.DeviceConstraint{{Attribute: "model", Operator: gpucrd.OpRegex, Value: "^A"}}, true},
+		{"set_contains", []gpucrd.DeviceConstraint{{Attribute: "tags", Operator: gpucrd.OpSetContains, Value: "fast"}}, true},
+		{"set_contains_any", []gpucrd.DeviceConstraint{{Attribute: "tags", Operator: gpucrd.OpSetContainsAny, Value: "slow,fast"}}, true},
+		{"set_contains_any none", []gpucrd.DeviceConstraint{{Attribute: "tags", Operator: gpucrd.OpSetContainsAny, Value: "slow,cheap"}}, false},
+		{"missing attribute", []gpucrd.DeviceConstraint{{Attribute: "missing", Operator: gpucrd.OpEqual, Value: ""}}, true},
+		{"multiple constraints all satisfied", []gpucrd.DeviceConstraint{
+			{Attribute: "model", Operator: gpucrd.OpEqual, Value: "A100"},
+			{Attribute: "memory", Operator: gpucrd.OpGreaterThanOrEqual, Value: "40"},
+		}, true},
+		{"multiple constraints one fails", []gpucrd.DeviceConstraint{
+			{Attribute: "model", Operator: gpucrd.OpEqual, Value: "A100"},
+			{Attribute: "memory", Operator: gpucrd.OpGreaterThanOrEqual, Value: "100"},
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchesConstraints(attrs, tt.constraints); got != tt.want {
+				t.Errorf("matchesConstraints() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAffinityScore(t *testing.T) {
+	attrs := map[string]string{
+		"model": "A100",
+		"zone":  "us-east-1a",
+	}
+
+	affinities := []gpucrd.DeviceAffinity{
+		{Attribute: "model", Operator: gpucrd.OpEqual, Value: "A100", Weight: 10},
+		{Attribute: "zone", Operator: gpucrd.OpEqual, Value: "us-east-1a", Weight: 5},
+		{Attribute: "zone", Operator: gpucrd.OpEqual, Value: "us-west-2a", Weight: 100},
+	}
+
+	if got, want := affinityScore(attrs, affinities), int64(15); got != want {
+		t.Errorf("affinityScore() = %v, want %v", got, want)
+	}
+
+	if got, want := affinityScore(attrs, nil), int64(0); got != want {
+		t.Errorf("affinityScore(nil) = %v, want %v", got, want)
+	}
+}
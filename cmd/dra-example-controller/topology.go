@@ -0,0 +1,140 @@
+/*
+ * Copyright 2023 The Kubernetes Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	nascrd "github.com/xcuda-io/k8s-xcuda-dra/api/example.com/resource/gpu/nas/v1alpha1"
+	gpucrd "github.com/xcuda-io/k8s-xcuda-dra/api/example.com/resource/gpu/v1alpha1"
+)
+
+// pickDeviceSet chooses claimParams.Count devices for a multi-GPU claim. If
+// TopologyPolicy is None, topology is ignored entirely and the first Count
+// eligible candidates are returned. Otherwise it maximizes the number of
+// pairs connected at or above effectiveMinRank(claimParams.MinLinkType)
+// according to topology. It returns nil if fewer than Count devices are
+// eligible, or if TopologyPolicy is Required and no subset meeting that rank
+// on every pair exists.
+func pickDeviceSet(available map[string]*nascrd.AllocatableGpu, remaining map[string]*gpuCapacity, claimParams *gpucrd.GpuClaimParametersSpec, selectors []gpucrd.DeviceSelector, topology map[string]map[string]nascrd.LinkType) []string {
+	candidates := eligibleCandidates(available, remaining, claimParams, selectors)
+	if len(candidates) < claimParams.Count {
+		return nil
+	}
+
+	if claimParams.TopologyPolicy == gpucrd.TopologyPolicyNone {
+		return candidates[:claimParams.Count]
+	}
+
+	minRank := effectiveMinRank(claimParams.MinLinkType)
+	subset := bestTopologySubset(candidates, claimParams.Count, topology, minRank)
+
+	if claimParams.TopologyPolicy == gpucrd.TopologyPolicyRequired {
+		maxEdges := claimParams.Count * (claimParams.Count - 1) / 2
+		if subsetScore(subset, topology, minRank) < maxEdges {
+			return nil
+		}
+	}
+
+	return subset
+}
+
+// effectiveMinRank turns an unset MinLinkType into a floor of 1, the lowest
+// rank any recognized LinkType carries, rather than LinkType("").Rank()'s 0 —
+// otherwise every candidate pair trivially clears the floor whether or not
+// topology records any link between them at all, and Required enforces
+// nothing.
+func effectiveMinRank(minLinkType nascrd.LinkType) int {
+	if rank := minLinkType.Rank(); rank > 0 {
+		return rank
+	}
+	return 1
+}
+
+// linkRank looks up the LinkType between a and b, checking both directions
+// since the topology matrix need not be populated symmetrically.
+func linkRank(topology map[string]map[string]nascrd.LinkType, a, b string) int {
+	if lt, ok := topology[a][b]; ok {
+		return lt.Rank()
+	}
+	if lt, ok := topology[b][a]; ok {
+		return lt.Rank()
+	}
+	return 0
+}
+
+// subsetScore counts the pairs in subset whose link rank is at or above
+// minRank.
+func subsetScore(subset []string, topology map[string]map[string]nascrd.LinkType, minRank int) int {
+	score := 0
+	for i := 0; i < len(subset); i++ {
+		for j := i + 1; j < len(subset); j++ {
+			if linkRank(topology, subset[i], subset[j]) >= minRank {
+				score++
+			}
+		}
+	}
+	return score
+}
+
+// bestTopologySubset finds the size-count subset of candidates that
+// maximizes subsetScore, using branch-and-bound to prune subsets whose
+// best possible score can no longer beat the best one found so far. The
+// per-node device count is small, so this stays well within budget even
+// though it is exponential in the worst case.
+func bestTopologySubset(candidates []string, count int, topology map[string]map[string]nascrd.LinkType, minRank int) []string {
+	if count <= 0 || len(candidates) < count {
+		return nil
+	}
+
+	var best []string
+	bestScore := -1
+	chosen := make([]string, 0, count)
+
+	var search func(start int)
+	search = func(start int) {
+		if len(chosen) == count {
+			score := subsetScore(chosen, topology, minRank)
+			if score > bestScore {
+				bestScore = score
+				best = append([]string(nil), chosen...)
+			}
+			return
+		}
+
+		remainingSlots := count - len(chosen)
+		if len(candidates)-start < remainingSlots {
+			return
+		}
+
+		// Upper bound on the score reachable from this partial subset:
+		// every edge already locked in, plus every edge that could still
+		// be formed by the remaining picks, assumed to all hit minRank.
+		currentScore := subsetScore(chosen, topology, minRank)
+		bestCaseAdditional := remainingSlots*(remainingSlots-1)/2 + remainingSlots*len(chosen)
+		if bestScore >= 0 && currentScore+bestCaseAdditional <= bestScore {
+			return
+		}
+
+		for i := start; i < len(candidates); i++ {
+			chosen = append(chosen, candidates[i])
+			search(i + 1)
+			chosen = chosen[:len(chosen)-1]
+		}
+	}
+	search(0)
+
+	return best
+}
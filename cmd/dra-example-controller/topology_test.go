@@ -0,0 +1,113 @@
+/*
+ * Copyright 2023 The Kubernetes Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"sort"
+	"testing"
+
+	nascrd "github.com/xcuda-io/k8s-xcuda-dra/api/example.com/resource/gpu/nas/v1alpha1"
+	gpucrd "github.com/xcuda-io/k8s-xcuda-dra/api/example.com/resource/gpu/v1alpha1"
+)
+
+func TestBestTopologySubset(t *testing.T) {
+	candidates := []string{"gpu0", "gpu1", "gpu2"}
+	topology := map[string]map[string]nascrd.LinkType{
+		"gpu0": {"gpu1": nascrd.LinkTypeNVLink},
+		"gpu1": {"gpu2": nascrd.LinkTypeCrossNUMA},
+	}
+
+	subset := bestTopologySubset(candidates, 2, topology, 1)
+	sort.Strings(subset)
+	if want := []string{"gpu0", "gpu1"}; !equalStrings(subset, want) {
+		t.Errorf("bestTopologySubset() = %v, want %v", subset, want)
+	}
+
+	if got := subsetScore(subset, topology, 1); got != 1 {
+		t.Errorf("subsetScore(%v) = %d, want 1", subset, got)
+	}
+}
+
+func TestPickDeviceSetTopologyNone(t *testing.T) {
+	available, remaining := threeEqualGpus()
+	claimParams := &gpucrd.GpuClaimParametersSpec{Count: 2, TopologyPolicy: gpucrd.TopologyPolicyNone}
+	topology := map[string]map[string]nascrd.LinkType{
+		"gpu0": {"gpu2": nascrd.LinkTypeNVLink},
+	}
+
+	got := pickDeviceSet(available, remaining, claimParams, nil, topology)
+	want := []string{"gpu0", "gpu1"}
+	if !equalStrings(got, want) {
+		t.Errorf("pickDeviceSet() with TopologyPolicyNone = %v, want %v (first eligible candidates, ignoring topology)", got, want)
+	}
+}
+
+func TestPickDeviceSetTopologyBestEffort(t *testing.T) {
+	available, remaining := threeEqualGpus()
+	claimParams := &gpucrd.GpuClaimParametersSpec{Count: 2, TopologyPolicy: gpucrd.TopologyPolicyBestEffort}
+	topology := map[string]map[string]nascrd.LinkType{
+		"gpu0": {"gpu2": nascrd.LinkTypeNVLink},
+	}
+
+	got := pickDeviceSet(available, remaining, claimParams, nil, topology)
+	sort.Strings(got)
+	want := []string{"gpu0", "gpu2"}
+	if !equalStrings(got, want) {
+		t.Errorf("pickDeviceSet() with TopologyPolicyBestEffort = %v, want %v (the linked pair)", got, want)
+	}
+}
+
+func TestPickDeviceSetTopologyRequired(t *testing.T) {
+	available, remaining := threeEqualGpus()
+	topology := map[string]map[string]nascrd.LinkType{
+		"gpu0": {"gpu2": nascrd.LinkTypeNVLink},
+	}
+
+	linked := &gpucrd.GpuClaimParametersSpec{Count: 2, TopologyPolicy: gpucrd.TopologyPolicyRequired}
+	if got := pickDeviceSet(available, remaining, linked, nil, topology); got == nil {
+		t.Errorf("pickDeviceSet() with TopologyPolicyRequired = nil, want the linked pair")
+	}
+
+	unlinked := &gpucrd.GpuClaimParametersSpec{Count: 3, TopologyPolicy: gpucrd.TopologyPolicyRequired}
+	if got := pickDeviceSet(available, remaining, unlinked, nil, topology); got != nil {
+		t.Errorf("pickDeviceSet() with TopologyPolicyRequired over an unlinked pair = %v, want nil", got)
+	}
+}
+
+// threeEqualGpus returns three identical, fully-available, non-shareable
+// GPUs named gpu0-gpu2, suitable as eligibleCandidates input.
+func threeEqualGpus() (map[string]*nascrd.AllocatableGpu, map[string]*gpuCapacity) {
+	available := make(map[string]*nascrd.AllocatableGpu)
+	remaining := make(map[string]*gpuCapacity)
+	for _, uuid := range []string{"gpu0", "gpu1", "gpu2"} {
+		available[uuid] = &nascrd.AllocatableGpu{UUID: uuid, MemoryMB: 80000, ComputePercent: 100}
+		remaining[uuid] = &gpuCapacity{MemoryMB: 80000, ComputePercent: 100}
+	}
+	return available, remaining
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,107 @@
+/*
+ * Copyright 2023 The Kubernetes Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var versionPattern = regexp.MustCompile(`^\d+(\.[0-9A-Za-z]+)*$`)
+
+// validateVersion checks that v (e.g. "12.4" or "535.104.05") is either
+// empty or looks like a dot-separated version string.
+func validateVersion(v string) error {
+	if v == "" {
+		return nil
+	}
+	if !versionPattern.MatchString(v) {
+		return fmt.Errorf("invalid version format: %q", v)
+	}
+	return nil
+}
+
+// versionAtLeast reports whether actual >= min as dot-separated versions. An
+// empty min is always satisfied; an empty actual never satisfies a nonempty
+// min.
+func versionAtLeast(actual, min string) bool {
+	if min == "" {
+		return true
+	}
+	if actual == "" {
+		return false
+	}
+	return compareVersions(actual, min) >= 0
+}
+
+// compareVersions compares two major.minor.patch-style versions component
+// by component. Numeric components compare numerically; a component that
+// doesn't parse as a number on either side falls back to a lexicographic
+// string comparison. A version with fewer components is padded with "0"
+// components, so "12" and "12.0" compare equal.
+func compareVersions(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+
+	n := len(as)
+	if len(bs) > n {
+		n = len(bs)
+	}
+
+	for i := 0; i < n; i++ {
+		ap, bp := "0", "0"
+		if i < len(as) {
+			ap = as[i]
+		}
+		if i < len(bs) {
+			bp = bs[i]
+		}
+		if c := compareVersionComponent(ap, bp); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareVersionComponent(a, b string) int {
+	an, aerr := strconv.Atoi(a)
+	bn, berr := strconv.Atoi(b)
+	if aerr == nil && berr == nil {
+		switch {
+		case an < bn:
+			return -1
+		case an > bn:
+			return 1
+		default:
+			return 0
+		}
+	}
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
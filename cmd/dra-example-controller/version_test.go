@@ -0,0 +1,61 @@
+/*
+ * Copyright 2023 The Kubernetes Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"12.4", "12.4", 0},
+		{"12.4", "12.3", 1},
+		{"12.3", "12.4", -1},
+		{"535.104.05", "535.104.4", -1},
+		{"12.10", "12.9", 1},
+		{"12", "12.0", 0},
+		{"12.4.1", "12.4", 1},
+		{"1.2.3", "1.2", 1},
+	}
+
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		actual, min string
+		want        bool
+	}{
+		{"12.4", "", true},
+		{"", "12.4", false},
+		{"", "", true},
+		{"12.4", "12.4", true},
+		{"12.5", "12.4", true},
+		{"12.3", "12.4", false},
+	}
+
+	for _, tt := range tests {
+		if got := versionAtLeast(tt.actual, tt.min); got != tt.want {
+			t.Errorf("versionAtLeast(%q, %q) = %v, want %v", tt.actual, tt.min, got, tt.want)
+		}
+	}
+}